@@ -0,0 +1,128 @@
+package wsrpc
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// BatchRequest describes a single call within a batched JSON-RPC request
+// submitted through BatchCall.
+type BatchRequest struct {
+	Method string
+	Args   []interface{}
+}
+
+// BatchResponse is the result of a single call within a batch submitted
+// through BatchCall.  Exactly one of Result and Error is set, mirroring the
+// JSON-RPC 2.0 response object.
+type BatchResponse struct {
+	Result json.RawMessage
+	Error  *Error
+}
+
+// batchCall tracks the in-flight responses for a single BatchCall, keyed by
+// the per-request IDs assigned when the batch was submitted.
+type batchCall struct {
+	mu     sync.Mutex
+	want   map[uint32]int // id -> index into the original request slice
+	remain int
+	resp   map[uint32]BatchResponse
+	done   chan error
+}
+
+// BatchCall submits reqs as a single JSON-RPC 2.0 batch request, emitted as
+// one websocket frame, and returns their responses in the same order as
+// reqs.  Per-call errors are reported in the corresponding BatchResponse.Error
+// field rather than failing the entire batch; the returned error is non-nil
+// only when the batch itself could not be submitted or answered (e.g. a
+// broken connection or a canceled context).
+func (c *Client) BatchCall(ctx context.Context, reqs []BatchRequest) (res []BatchResponse, err error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	defer func() {
+		if err != nil {
+			if e := ctx.Err(); e != nil {
+				err = e
+			}
+		}
+	}()
+
+	ids := make([]uint32, len(reqs))
+	bc := &batchCall{
+		want:   make(map[uint32]int, len(reqs)),
+		remain: len(reqs),
+		resp:   make(map[uint32]BatchResponse, len(reqs)),
+		done:   make(chan error, 1),
+	}
+	wire := make([]wireRequest, len(reqs))
+	c.callMu.Lock()
+	for i, r := range reqs {
+		id := c.nextID()
+		ids[i] = id
+		bc.want[id] = i
+		c.batches[id] = bc
+		wire[i] = wireRequest{JSONRPC: "2.0", Method: r.Method, Params: r.Args, ID: id}
+	}
+	c.callMu.Unlock()
+
+	c.writing.Lock()
+	err = c.currentWS().WriteJSON(wire)
+	c.writing.Unlock()
+	if err != nil {
+		c.callMu.Lock()
+		for _, id := range ids {
+			delete(c.batches, id)
+		}
+		c.callMu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.errc:
+		return nil, c.err
+	case err := <-bc.done:
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bc.mu.Lock()
+	res = make([]BatchResponse, len(reqs))
+	for id, idx := range bc.want {
+		res[idx] = bc.resp[id]
+	}
+	bc.mu.Unlock()
+	return res, nil
+}
+
+// handleBatch dispatches every response contained in a batch frame to its
+// waiting BatchCall, completing each batch once all of its responses have
+// arrived.
+func (c *Client) handleBatch(batch []rawResponse) {
+	c.callMu.Lock()
+	defer c.callMu.Unlock()
+
+	done := make(map[*batchCall]bool)
+	for _, r := range batch {
+		bc, ok := c.batches[r.ID]
+		if !ok {
+			continue
+		}
+		delete(c.batches, r.ID)
+
+		bc.mu.Lock()
+		bc.resp[r.ID] = BatchResponse{Result: r.Result, Error: r.Error}
+		bc.remain--
+		if bc.remain == 0 {
+			done[bc] = true
+		}
+		bc.mu.Unlock()
+	}
+	for bc := range done {
+		bc.done <- nil
+	}
+}
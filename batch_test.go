@@ -0,0 +1,87 @@
+package wsrpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestHandleBatchCorrelatesByID checks that handleBatch routes each response
+// in a batch frame back to the BatchResponse slot matching its request ID,
+// independent of the order the responses appear in the frame.
+func TestHandleBatchCorrelatesByID(t *testing.T) {
+	c := &Client{batches: make(map[uint32]*batchCall)}
+
+	bc := &batchCall{
+		want:   map[uint32]int{1: 0, 2: 1, 3: 2},
+		remain: 3,
+		resp:   make(map[uint32]BatchResponse),
+		done:   make(chan error, 1),
+	}
+	c.batches[1] = bc
+	c.batches[2] = bc
+	c.batches[3] = bc
+
+	// Responses arrive out of order and interleaved with an unknown ID,
+	// which must be ignored rather than correlated to any batch.
+	c.handleBatch([]rawResponse{
+		{ID: 3, Result: json.RawMessage(`"three"`)},
+		{ID: 99},
+		{ID: 1, Result: json.RawMessage(`"one"`)},
+		{ID: 2, Error: &Error{Message: "boom"}},
+	})
+
+	select {
+	case err := <-bc.done:
+		if err != nil {
+			t.Fatalf("done sent error %v, want nil", err)
+		}
+	default:
+		t.Fatal("batchCall did not complete once all responses arrived")
+	}
+
+	if got := string(bc.resp[1].Result); got != `"one"` {
+		t.Errorf("id 1 result = %s, want \"one\"", got)
+	}
+	if got := string(bc.resp[3].Result); got != `"three"` {
+		t.Errorf("id 3 result = %s, want \"three\"", got)
+	}
+	if bc.resp[2].Error == nil || bc.resp[2].Error.Message != "boom" {
+		t.Errorf("id 2 error = %v, want {Message: boom}", bc.resp[2].Error)
+	}
+	if _, ok := c.batches[1]; ok {
+		t.Error("batch entry for id 1 should be removed once answered")
+	}
+}
+
+// TestHandleBatchPartial checks that a batchCall is not completed until every
+// one of its requests has a response, even across multiple handleBatch
+// calls.
+func TestHandleBatchPartial(t *testing.T) {
+	c := &Client{batches: make(map[uint32]*batchCall)}
+
+	bc := &batchCall{
+		want:   map[uint32]int{10: 0, 11: 1},
+		remain: 2,
+		resp:   make(map[uint32]BatchResponse),
+		done:   make(chan error, 1),
+	}
+	c.batches[10] = bc
+	c.batches[11] = bc
+
+	c.handleBatch([]rawResponse{{ID: 10}})
+	select {
+	case <-bc.done:
+		t.Fatal("batchCall completed before its second response arrived")
+	default:
+	}
+
+	c.handleBatch([]rawResponse{{ID: 11}})
+	select {
+	case err := <-bc.done:
+		if err != nil {
+			t.Fatalf("done sent error %v, want nil", err)
+		}
+	default:
+		t.Fatal("batchCall did not complete once its final response arrived")
+	}
+}
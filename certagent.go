@@ -0,0 +1,106 @@
+package wsrpc
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// certAgentCertRequest asks the agent for the client certificate (and its
+// embedded public key) to present during the TLS handshake.
+type certAgentCertRequest struct{}
+
+type certAgentCertResponse struct {
+	Certificate []byte // DER-encoded X.509 certificate
+	Err         string
+}
+
+// certAgentSignRequest asks the agent to sign digest with the private key
+// backing the certificate returned by certAgentCertRequest.
+type certAgentSignRequest struct {
+	Digest   []byte
+	HashFunc crypto.Hash
+}
+
+type certAgentSignResponse struct {
+	Signature []byte
+	Err       string
+}
+
+// agentSigner implements crypto.Signer by forwarding Sign calls to an
+// out-of-process agent over conn, following the same request/response
+// pattern as the existing WSRPCAGENT_SOCK plumbing used by the CLI.  The
+// agent, not this process, holds the private key.
+type agentSigner struct {
+	conn net.Conn
+	mu   sync.Mutex
+	pub  crypto.PublicKey
+}
+
+func (s *agentSigner) Public() crypto.PublicKey { return s.pub }
+
+func (s *agentSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enc := json.NewEncoder(s.conn)
+	if err := enc.Encode(&certAgentSignRequest{Digest: digest, HashFunc: opts.HashFunc()}); err != nil {
+		return nil, fmt.Errorf("wsrpc: cert agent sign request: %w", err)
+	}
+	var resp certAgentSignResponse
+	if err := json.NewDecoder(s.conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("wsrpc: cert agent sign response: %w", err)
+	}
+	if resp.Err != "" {
+		return nil, errors.New(resp.Err)
+	}
+	return resp.Signature, nil
+}
+
+// newAgentSigner fetches the client certificate held by the agent on conn
+// and returns it as a tls.Certificate whose PrivateKey is a crypto.Signer
+// that forwards Sign calls to the agent.
+func newAgentSigner(conn net.Conn) (*tls.Certificate, error) {
+	enc := json.NewEncoder(conn)
+	if err := enc.Encode(&certAgentCertRequest{}); err != nil {
+		return nil, fmt.Errorf("wsrpc: cert agent cert request: %w", err)
+	}
+	var resp certAgentCertResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("wsrpc: cert agent cert response: %w", err)
+	}
+	if resp.Err != "" {
+		return nil, errors.New(resp.Err)
+	}
+	cert, err := x509.ParseCertificate(resp.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("wsrpc: cert agent certificate: %w", err)
+	}
+	signer := &agentSigner{conn: conn, pub: cert.PublicKey}
+	return &tls.Certificate{
+		Certificate: [][]byte{resp.Certificate},
+		PrivateKey:  signer,
+		Leaf:        cert,
+	}, nil
+}
+
+// WithClientCertAgent enables TLS client certificate authentication backed
+// by an out-of-process agent reachable over conn (typically a unix socket,
+// such as the one named by the CLI's -cert-agent-sock flag), rather than loading the
+// certificate's private key into this process.  This allows hardware-token
+// backed keys to be used for mTLS without the key material ever being held
+// by the caller.
+//
+// conn is used for the lifetime of the dialed Client and is closed along
+// with it.
+func WithClientCertAgent(conn net.Conn) Option {
+	return func(o *options) {
+		o.certAgentConn = conn
+	}
+}
@@ -24,15 +24,23 @@ const sockEnv = "WSRPCAGENT_SOCK"
 const authEnv = "WSRPCAGENT_AUTH"
 
 var (
-	fs       = flag.NewFlagSet("", flag.ExitOnError)
-	cFlag    = fs.String("c", "", "Root certificate PEM file")
-	userFlag = fs.String("u", "", "User")
-	passFlag = fs.String("p", "", "Password")
+	fs            = flag.NewFlagSet("", flag.ExitOnError)
+	cFlag         = fs.String("c", "", "Root certificate PEM file")
+	userFlag      = fs.String("u", "", "User")
+	passFlag      = fs.String("p", "", "Password")
+	batchFlag     = fs.String("batch", "", "JSON file of {method,params} objects to dispatch as one batch request")
+	bearerFlag    = fs.String("bearer", "", "Bearer token, used instead of -u/-p")
+	tokenFileFlag = fs.String("token-file", "", "File containing a bearer token, used instead of -u/-p")
+	subscribeFlag = fs.String("subscribe", "", "Subscribe to a notification method instead of issuing a single call")
+	certAgentFlag = fs.String("cert-agent-sock", "", "Unix socket of an agent holding the TLS client certificate and key")
 )
 
 func main() {
 	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, "usage: wsrpc address [flags] method [arg]")
+		fmt.Fprintln(os.Stderr, "       wsrpc address [flags] method:arg [method:arg ...]")
+		fmt.Fprintln(os.Stderr, "       wsrpc address [flags] -batch file.json")
+		fmt.Fprintln(os.Stderr, "       wsrpc address [flags] -subscribe method [arg]")
 		fs.PrintDefaults()
 		os.Exit(2)
 	}
@@ -42,15 +50,36 @@ func main() {
 	addr := os.Args[1]
 	fs.Parse(os.Args[2:])
 	n := fs.NArg()
-	if n != 1 && n != 2 { // Expect method and optionally a JSON array arg
-		fs.Usage()
-	}
-	method, arg := fs.Arg(0), ""
-	if n == 2 {
-		arg = fs.Arg(1)
-		if arg != "" && arg[0] != '[' {
-			log.Fatal("parameter must be JSON array")
+
+	var method, arg string
+	var batchSpecs []string
+	switch {
+	case *subscribeFlag != "":
+		if n > 1 {
+			fs.Usage()
+		}
+		if n == 1 {
+			arg = fs.Arg(0)
+			if arg != "" && arg[0] != '[' {
+				log.Fatal("parameter must be JSON array")
+			}
 		}
+	case *batchFlag != "":
+		if n != 0 {
+			fs.Usage()
+		}
+	case n >= 1 && strings.Contains(fs.Arg(0), ":"):
+		batchSpecs = fs.Args()
+	case n == 1 || n == 2: // Expect method and optionally a JSON array arg
+		method = fs.Arg(0)
+		if n == 2 {
+			arg = fs.Arg(1)
+			if arg != "" && arg[0] != '[' {
+				log.Fatal("parameter must be JSON array")
+			}
+		}
+	default:
+		fs.Usage()
 	}
 	ctx := context.Background()
 
@@ -68,8 +97,26 @@ func main() {
 		}
 	}
 
+	bearer := *bearerFlag
+	if *tokenFileFlag != "" {
+		b, err := ioutil.ReadFile(*tokenFileFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		bearer = strings.TrimSpace(string(b))
+	}
+
 	sock, auth := os.Getenv(sockEnv), os.Getenv(authEnv)
 	if sock != "" || auth != "" {
+		if *batchFlag != "" || batchSpecs != nil {
+			log.Fatal("batch requests are not supported through " + sockEnv)
+		}
+		if *subscribeFlag != "" {
+			log.Fatal("subscriptions are not supported through " + sockEnv)
+		}
+		if *certAgentFlag != "" {
+			log.Fatal("-cert-agent-sock is not supported through " + sockEnv)
+		}
 		conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: sock, Net: "unix"})
 		if err != nil {
 			log.Fatal(err)
@@ -79,6 +126,7 @@ func main() {
 			RootCert: string(pem),
 			User:     *userFlag,
 			Pass:     *passFlag,
+			Bearer:   bearer,
 			Method:   method,
 			Params:   arg,
 		})
@@ -88,15 +136,102 @@ func main() {
 		return
 	}
 
-	c, err := wsrpc.Dial(ctx, addr, wsrpc.WithTLSConfig(tc), wsrpc.WithBasicAuth(*userFlag, *passFlag))
+	authOpt := wsrpc.WithBasicAuth(*userFlag, *passFlag)
+	if bearer != "" {
+		authOpt = wsrpc.WithBearerToken(bearer)
+	}
+	dialOpts := []wsrpc.Option{wsrpc.WithTLSConfig(tc), authOpt}
+	if *subscribeFlag != "" {
+		// Long-lived subscriptions should survive transient network blips.
+		dialOpts = append(dialOpts, wsrpc.WithAutoReconnect(wsrpc.ReconnectPolicy{}))
+	}
+	if *certAgentFlag != "" {
+		conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: *certAgentFlag, Net: "unix"})
+		if err != nil {
+			log.Fatal(err)
+		}
+		dialOpts = append(dialOpts, wsrpc.WithClientCertAgent(conn))
+	}
+	c, err := wsrpc.Dial(ctx, addr, dialOpts...)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := run(ctx, c, method, arg); err != nil {
+	switch {
+	case *subscribeFlag != "":
+		err = runSubscribe(ctx, c, *subscribeFlag, arg)
+	case *batchFlag != "":
+		err = runBatchFile(ctx, c, *batchFlag)
+	case batchSpecs != nil:
+		err = runBatch(ctx, c, batchSpecs)
+	default:
+		err = run(ctx, c, method, arg)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
 
+// runBatch parses specs of the form "method" or "method:[args]" and
+// dispatches them as a single JSON-RPC batch request.
+func runBatch(ctx context.Context, c *wsrpc.Client, specs []string) error {
+	defer c.Close()
+	reqs := make([]wsrpc.BatchRequest, len(specs))
+	for i, spec := range specs {
+		method, arg := spec, ""
+		if idx := strings.IndexByte(spec, ':'); idx >= 0 {
+			method, arg = spec[:idx], spec[idx+1:]
+		}
+		var args []interface{}
+		if arg != "" {
+			if err := json.NewDecoder(strings.NewReader(arg)).Decode(&args); err != nil {
+				return fmt.Errorf("%s: %w", spec, err)
+			}
+		}
+		reqs[i] = wsrpc.BatchRequest{Method: method, Args: args}
+	}
+	return batchCall(ctx, c, reqs)
+}
+
+// batchFileEntry is one element of the JSON array read from a -batch file.
+type batchFileEntry struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+func runBatchFile(ctx context.Context, c *wsrpc.Client, path string) error {
+	defer c.Close()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var entries []batchFileEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+	reqs := make([]wsrpc.BatchRequest, len(entries))
+	for i, e := range entries {
+		reqs[i] = wsrpc.BatchRequest{Method: e.Method, Args: e.Params}
+	}
+	return batchCall(ctx, c, reqs)
+}
+
+func batchCall(ctx context.Context, c *wsrpc.Client, reqs []wsrpc.BatchRequest) error {
+	responses, err := c.BatchCall(ctx, reqs)
+	if err != nil {
+		return err
+	}
+	for i, res := range responses {
+		if res.Error != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", reqs[i].Method, res.Error)
+			continue
+		}
+		if err := pp(res.Result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func run(ctx context.Context, c *wsrpc.Client, method string, arg string) error {
 	defer c.Close()
 	var args []interface{}
@@ -112,6 +247,28 @@ func run(ctx context.Context, c *wsrpc.Client, method string, arg string) error
 	return pp(res)
 }
 
+// runSubscribe subscribes to method and prints each notification as it
+// arrives until the connection is closed.
+func runSubscribe(ctx context.Context, c *wsrpc.Client, method string, arg string) error {
+	defer c.Close()
+	var args []interface{}
+	if arg != "" {
+		if err := json.NewDecoder(strings.NewReader(arg)).Decode(&args); err != nil {
+			return err
+		}
+	}
+	sub, err := c.Subscribe(ctx, method, args...)
+	if err != nil {
+		return err
+	}
+	for params := range sub.C {
+		if err := pp(params); err != nil {
+			return err
+		}
+	}
+	return c.Err()
+}
+
 func pp(res json.RawMessage) error {
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
@@ -123,6 +280,7 @@ type agentArgs struct {
 	RootCert string
 	User     string
 	Pass     string
+	Bearer   string
 	Method   string
 	Params   string
 }
@@ -0,0 +1,49 @@
+package wsrpc
+
+import (
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// WithBearerToken enables bearer token authentication, sending an
+// "Authorization: Bearer <token>" header instead of basic auth.  This is
+// useful when the websocket server sits behind an OAuth2-protected gateway.
+//
+// For tokens that expire and need periodic refreshing, use WithTokenSource
+// instead.
+func WithBearerToken(token string) Option {
+	return func(o *options) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithTokenSource enables bearer token authentication using ts, which is
+// queried for a fresh token each time the client (re)dials.  This allows
+// short-lived tokens, such as those issued by an OAuth2 device-code flow, to
+// be refreshed transparently across reconnects.
+func WithTokenSource(ts oauth2.TokenSource) Option {
+	return func(o *options) {
+		o.tokenSource = ts
+	}
+}
+
+// tokenFromSource queries o.tokenSource, if set, for a token and sets the
+// Authorization header used for the next dial.
+func tokenFromSource(o *options) error {
+	if o.tokenSource == nil {
+		return nil
+	}
+	tok, err := o.tokenSource.Token()
+	if err != nil {
+		return err
+	}
+	if o.header == nil {
+		o.header = make(http.Header)
+	}
+	o.header.Set("Authorization", tok.Type()+" "+tok.AccessToken)
+	return nil
+}
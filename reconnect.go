@@ -0,0 +1,136 @@
+package wsrpc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ErrDisconnected is returned by Call and BatchCall when the underlying
+// connection breaks while auto-reconnect (see WithAutoReconnect) is
+// enabled.  CallIdempotent retries automatically on this error; callers
+// using Call directly may do the same once the WithOnReconnect callback
+// indicates the client has reconnected.
+var ErrDisconnected = errors.New("wsrpc: disconnected, reconnecting")
+
+// ReconnectPolicy describes the exponential backoff used between redial
+// attempts by WithAutoReconnect.  Backoff doubles after each failed attempt,
+// up to MaxBackoff, and is randomized (full jitter) to avoid many clients
+// reconnecting in lockstep.
+type ReconnectPolicy struct {
+	// InitialBackoff is the backoff ceiling used for the first redial
+	// attempt.  Defaults to 250ms if zero.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the backoff ceiling for later attempts.  Defaults to
+	// 30s if zero.
+	MaxBackoff time.Duration
+}
+
+func (p ReconnectPolicy) withDefaults() ReconnectPolicy {
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 250 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 30 * time.Second
+	}
+	return p
+}
+
+// backoff returns a jittered backoff duration for the given zero-based
+// attempt number.
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.InitialBackoff
+	for i := 0; i < attempt; i++ {
+		ceiling *= 2
+		if ceiling > p.MaxBackoff {
+			ceiling = p.MaxBackoff
+			break
+		}
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// WithAutoReconnect enables transparent reconnection: if the connection
+// breaks unexpectedly, the client redials using policy's exponential
+// backoff, rather than failing permanently.  Active Subscriptions are
+// re-registered after each successful reconnect.
+//
+// Calls in flight when the connection breaks fail with ErrDisconnected;
+// CallIdempotent may be used in place of Call for calls that are safe to
+// resubmit once reconnected.
+func WithAutoReconnect(policy ReconnectPolicy) Option {
+	return func(o *options) {
+		o.reconnect = true
+		o.reconnectPolicy = policy.withDefaults()
+	}
+}
+
+// WithOnDisconnect registers a callback invoked with the error that broke
+// the connection each time auto-reconnect begins redialing.
+func WithOnDisconnect(f func(error)) Option {
+	return func(o *options) {
+		o.onDisconnect = f
+	}
+}
+
+// WithOnReconnect registers a callback invoked after auto-reconnect
+// successfully redials and has started re-registering Subscriptions; the
+// re-registration calls may still be in flight when it runs.
+func WithOnReconnect(f func()) Option {
+	return func(o *options) {
+		o.onReconnect = f
+	}
+}
+
+// replaySubscriptions re-registers every active Subscription after a
+// reconnect.  Errors are not surfaced: a subscription that fails to
+// re-register simply stops receiving notifications until the caller
+// notices (e.g. via a timeout) and creates a new one.
+//
+// Each replay Call runs in its own goroutine rather than blocking the
+// caller: replaySubscriptions is invoked by in() before it loops back to
+// readLoop, and Call cannot complete until readLoop is running again to
+// read the response.
+func (c *Client) replaySubscriptions() {
+	c.subMu.Lock()
+	var subs []*Subscription
+	for _, list := range c.subs {
+		subs = append(subs, list...)
+	}
+	c.subMu.Unlock()
+
+	for _, s := range subs {
+		s := s
+		go c.Call(context.Background(), s.Method, nil, s.args...)
+	}
+}
+
+// CallIdempotent behaves like Call, except that if the connection breaks
+// mid-call on a client dialed with WithAutoReconnect, it waits for the next
+// reconnect attempt to resolve and resubmits the call, rather than
+// returning ErrDisconnected to the caller.  Only use this for calls that are
+// safe to execute more than once.
+func (c *Client) CallIdempotent(ctx context.Context, method string, result interface{}, args ...interface{}) error {
+	for {
+		err := c.Call(ctx, method, result, args...)
+		if !errors.Is(err, ErrDisconnected) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		c.reconnectMu.Lock()
+		resolved := c.reconnectCh
+		c.reconnectMu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.errc:
+			return c.err
+		case <-resolved:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package wsrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReconnectPolicyBackoff(t *testing.T) {
+	p := ReconnectPolicy{}.withDefaults()
+
+	ceilings := []struct {
+		attempt int
+		want    int64
+	}{
+		{0, int64(p.InitialBackoff)},
+		{1, int64(2 * p.InitialBackoff)},
+		{2, int64(4 * p.InitialBackoff)},
+	}
+	for _, tc := range ceilings {
+		for i := 0; i < 50; i++ {
+			d := p.backoff(tc.attempt)
+			if int64(d) < 0 || int64(d) > tc.want {
+				t.Fatalf("attempt %d: backoff = %v, want in [0, %v]", tc.attempt, d, tc.want)
+			}
+		}
+	}
+}
+
+func TestReconnectPolicyBackoffCapsAtMax(t *testing.T) {
+	p := ReconnectPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}.withDefaults()
+	for i := 0; i < 50; i++ {
+		d := p.backoff(20) // far enough to have doubled past MaxBackoff
+		if int64(d) > int64(p.MaxBackoff) {
+			t.Fatalf("backoff = %v, want capped at MaxBackoff %v", d, p.MaxBackoff)
+		}
+	}
+}
+
+// TestAutoReconnectReplaysSubscriptionWithoutDeadlock dials a real websocket
+// server, establishes a Subscription, forces the server to drop the
+// connection, and checks that a Call issued after the client reconnects
+// still completes.  replaySubscriptions previously ran its replay Call
+// synchronously inside in(), before in() looped back into readLoop, so the
+// replay could never receive a response and every later Call hung forever.
+func TestAutoReconnectReplaysSubscriptionWithoutDeadlock(t *testing.T) {
+	var upgrader websocket.Upgrader
+	var connNum int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		first := atomic.AddInt32(&connNum, 1) == 1
+
+		for {
+			var req wireRequest
+			if err := ws.ReadJSON(&req); err != nil {
+				return
+			}
+			if err := ws.WriteJSON(rawResponse{ID: req.ID}); err != nil {
+				return
+			}
+			if first {
+				// Answer exactly one request (the initial Subscribe
+				// registration), then drop the connection to force a
+				// reconnect.
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	reconnected := make(chan struct{})
+	addr := "ws" + strings.TrimPrefix(server.URL, "http")
+	c, err := Dial(context.Background(), addr, WithAutoReconnect(ReconnectPolicy{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}), WithOnReconnect(func() { close(reconnected) }))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	sub, err := c.Subscribe(context.Background(), "sub")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	// onReconnect only fires once in() has started the subscription replays and is
+	// about to loop back into readLoop, so waiting for it rules out the
+	// unrelated race of writing a Call to the stale pre-reconnect
+	// connection; it does NOT wait out the deadlock this test guards
+	// against, since a blocked replaySubscriptions call would keep in()
+	// from ever reaching the onReconnect callback in the first place.
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client never reconnected (possible deadlock in replaySubscriptions)")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Call(ctx, "ping", nil); err != nil {
+		t.Fatalf("Call after reconnect did not complete (possible deadlock): %v", err)
+	}
+}
@@ -0,0 +1,453 @@
+/*
+Package wsrpc provides a partial implementation of a JSON-RPC 2.0 websocket
+client.  Inspired by net/rpc, clients call methods by their name with arguments
+and return values marshaled by encoding/json.  The client may be used to create
+convenience calls with types specific to an application.
+
+Batched requests, as described by the JSON-RPC 2.0 specification, are
+supported through BatchCall.  JSON-RPC notifications may be received through
+Subscribe.  WithAutoReconnect enables transparent redialing, with
+Subscriptions resumed automatically, after an unexpected disconnect.
+
+This package currently does not implement keyed request parameters when
+performing calls.
+*/
+package wsrpc // import "github.com/jrick/wsrpc"
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/oauth2"
+)
+
+// Error represents a JSON-RPC error object.
+type Error struct {
+	Code    int64           `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// rawResponse is decoded from every message read off the websocket.  It
+// doubles as a notification when Method is set and ID is zero.
+type rawResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+	ID     uint32          `json:"id"`
+
+	// Request fields for notifications
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// wireRequest is the JSON-RPC 2.0 request object written to the websocket.
+type wireRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params,omitempty"`
+	ID      uint32        `json:"id"`
+}
+
+type call struct {
+	method string
+	result interface{}
+	err    chan error
+}
+
+// Client implements JSON-RPC calls and notifications over a websocket.
+type Client struct {
+	atomicSeq uint32
+	addr      string
+	o         *options
+
+	connMu sync.RWMutex
+	ws     *websocket.Conn
+
+	calls   map[uint32]*call
+	batches map[uint32]*batchCall
+	callMu  sync.Mutex
+	writing sync.Mutex
+	errc    chan struct{} // closed after err is set
+	err     error
+
+	subMu sync.Mutex
+	subs  map[string][]*Subscription
+
+	certAgentConn net.Conn
+
+	closeOnce sync.Once
+	closeCh   chan struct{} // closed by Close to abort a reconnect in progress
+
+	reconnectMu sync.Mutex
+	reconnectCh chan struct{} // closed when a disconnect is resolved, one way or another
+}
+
+type options struct {
+	tls           *tls.Config
+	header        http.Header
+	dial          DialFunc
+	tokenSource   oauth2.TokenSource
+	certAgentConn net.Conn
+
+	reconnect       bool
+	reconnectPolicy ReconnectPolicy
+	onDisconnect    func(error)
+	onReconnect     func()
+}
+
+// Option modifies the behavior of Dial.
+type Option func(*options)
+
+// DialFunc dials a network connection.  Custom dialers may utilize a proxy or
+// set connection timeouts.
+type DialFunc func(network, address string) (net.Conn, error)
+
+// WithDial specifies a custom dial function.
+func WithDial(dial DialFunc) Option {
+	return func(o *options) {
+		o.dial = dial
+	}
+}
+
+// WithBasicAuth enables basic access authentication using the user and
+// password.
+func WithBasicAuth(user, pass string) Option {
+	return func(o *options) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Add("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+	}
+}
+
+// WithTLSConfig specifies a TLS config when connecting to a secure websocket
+// (wss) server.  If unspecified, the default TLS config will be used.
+func WithTLSConfig(tls *tls.Config) Option {
+	return func(o *options) {
+		o.tls = tls
+	}
+}
+
+// connect dials addr and performs any per-connection setup (token refresh,
+// client certificate agent) described by o.
+func connect(ctx context.Context, addr string, o *options) (*websocket.Conn, error) {
+	if err := tokenFromSource(o); err != nil {
+		return nil, err
+	}
+	if o.certAgentConn != nil && (o.tls == nil || len(o.tls.Certificates) == 0) {
+		cert, err := newAgentSigner(o.certAgentConn)
+		if err != nil {
+			return nil, err
+		}
+		tc := o.tls.Clone()
+		if tc == nil {
+			tc = new(tls.Config)
+		}
+		tc.Certificates = []tls.Certificate{*cert}
+		o.tls = tc
+	}
+	dialer := websocket.Dialer{
+		NetDial:           o.dial,
+		TLSClientConfig:   o.tls,
+		EnableCompression: true,
+	}
+	ws, _, err := dialer.DialContext(ctx, addr, o.header)
+	return ws, err
+}
+
+// Dial establishes an RPC client connection to the server described by addr.
+// Addr must be the URL of the websocket, e.g., "wss://[::1]:9109/ws".
+func Dial(ctx context.Context, addr string, opts ...Option) (*Client, error) {
+	var o options
+	for _, f := range opts {
+		f(&o)
+	}
+	ws, err := connect(ctx, addr, &o)
+	if err != nil {
+		if o.certAgentConn != nil {
+			o.certAgentConn.Close()
+		}
+		return nil, err
+	}
+	c := &Client{
+		addr:          addr,
+		o:             &o,
+		ws:            ws,
+		calls:         make(map[uint32]*call),
+		batches:       make(map[uint32]*batchCall),
+		subs:          make(map[string][]*Subscription),
+		errc:          make(chan struct{}),
+		certAgentConn: o.certAgentConn,
+		closeCh:       make(chan struct{}),
+	}
+	go c.in()
+	return c, nil
+}
+
+// Address returns the dialed network address.
+func (c *Client) Address() string {
+	return c.addr
+}
+
+func (c *Client) currentWS() *websocket.Conn {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.ws
+}
+
+func (c *Client) setWS(ws *websocket.Conn) {
+	c.connMu.Lock()
+	c.ws = ws
+	c.connMu.Unlock()
+}
+
+// Close closes the underlying websocket connection and disables any further
+// reconnection attempts.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.closeCh) })
+	if c.certAgentConn != nil {
+		c.certAgentConn.Close()
+	}
+	return c.currentWS().Close()
+}
+
+// nextID returns the next request ID, skipping zero, which is reserved to
+// indicate missing ID fields in notifications.
+func (c *Client) nextID() uint32 {
+	id := atomic.AddUint32(&c.atomicSeq, 1)
+	if id == 0 {
+		id = atomic.AddUint32(&c.atomicSeq, 1)
+	}
+	return id
+}
+
+// in supervises the connection, running readLoop until it returns, and
+// either ending the client (closing errc) or, when auto-reconnect is
+// enabled, redialing and resuming.
+func (c *Client) in() {
+	for {
+		err := c.readLoop(c.currentWS())
+		if !c.o.reconnect || c.isClosing() {
+			c.failPending(err)
+			c.closeSubscriptions()
+			c.err = err
+			close(c.errc)
+			return
+		}
+
+		if c.o.onDisconnect != nil {
+			c.o.onDisconnect(err)
+		}
+
+		c.reconnectMu.Lock()
+		c.reconnectCh = make(chan struct{})
+		resolved := c.reconnectCh
+		c.reconnectMu.Unlock()
+
+		c.failPending(ErrDisconnected)
+
+		ws, ok := c.reconnectLoop()
+		close(resolved)
+		if !ok {
+			closedErr := errors.New("wsrpc: closed while reconnecting")
+			c.failPending(closedErr)
+			c.closeSubscriptions()
+			c.err = closedErr
+			close(c.errc)
+			return
+		}
+		c.setWS(ws)
+		c.replaySubscriptions()
+		if c.o.onReconnect != nil {
+			c.o.onReconnect()
+		}
+	}
+}
+
+// reconnectLoop redials with exponential backoff until a connection
+// succeeds or Close is called, in which case ok is false.
+func (c *Client) reconnectLoop() (ws *websocket.Conn, ok bool) {
+	policy := c.o.reconnectPolicy.withDefaults()
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-c.closeCh:
+			return nil, false
+		case <-time.After(policy.backoff(attempt)):
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-c.closeCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		ws, err := connect(ctx, c.addr, c.o)
+		cancel()
+		if err == nil {
+			return ws, true
+		}
+		select {
+		case <-c.closeCh:
+			return nil, false
+		default:
+		}
+	}
+}
+
+// isClosing reports whether Close has been called.
+func (c *Client) isClosing() bool {
+	select {
+	case <-c.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// readLoop reads and dispatches messages from ws until an error occurs.
+func (c *Client) readLoop(ws *websocket.Conn) error {
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		data = bytes.TrimLeft(data, " \t\r\n")
+		if len(data) > 0 && data[0] == '[' {
+			var batch []rawResponse
+			if err := json.Unmarshal(data, &batch); err != nil {
+				return err
+			}
+			c.handleBatch(batch)
+			continue
+		}
+
+		var resp rawResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return err
+		}
+		if !c.handleResponse(resp) {
+			return errors.New("wsrpc: unknown response ID")
+		}
+	}
+}
+
+// failPending delivers err to every call and batch awaiting a response on
+// the connection that just broke.
+func (c *Client) failPending(err error) {
+	c.callMu.Lock()
+	calls := c.calls
+	c.calls = make(map[uint32]*call)
+	batches := c.batches
+	c.batches = make(map[uint32]*batchCall)
+	c.callMu.Unlock()
+
+	for _, cl := range calls {
+		cl.err <- err
+	}
+	done := make(map[*batchCall]bool)
+	for _, bc := range batches {
+		if !done[bc] {
+			done[bc] = true
+			bc.done <- err
+		}
+	}
+}
+
+// handleResponse dispatches a single decoded response or notification.  It
+// reports whether the response was recognized.
+func (c *Client) handleResponse(resp rawResponse) bool {
+	// Zero IDs are never used by requests.
+	if resp.Method != "" && resp.Result == nil && resp.Error == nil && resp.ID == 0 {
+		c.dispatchNotification(resp.Method, resp.Params)
+		return true
+	}
+
+	c.callMu.Lock()
+	cl, ok := c.calls[resp.ID]
+	if ok {
+		delete(c.calls, resp.ID)
+	}
+	c.callMu.Unlock()
+	if !ok {
+		return false
+	}
+	var err error
+	if resp.Error != nil {
+		err = resp.Error
+	} else if cl.result != nil {
+		err = json.NewDecoder(bytes.NewReader(resp.Result)).Decode(cl.result)
+	}
+	cl.err <- err
+	return true
+}
+
+// Call performs the JSON-RPC described by method with positional parameters
+// passed through args.  Result should point to an object to unmarshal the
+// result, or equal nil to discard the result.
+func (c *Client) Call(ctx context.Context, method string, result interface{}, args ...interface{}) (err error) {
+	defer func() {
+		if err != nil {
+			if e := ctx.Err(); e != nil {
+				err = e
+			}
+		}
+	}()
+
+	id := c.nextID()
+	cl := &call{
+		method: method,
+		result: result,
+		err:    make(chan error, 1),
+	}
+	c.callMu.Lock()
+	c.calls[id] = cl
+	c.callMu.Unlock()
+
+	request := &wireRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  args,
+		ID:      id,
+	}
+	c.writing.Lock()
+	err = c.currentWS().WriteJSON(request)
+	c.writing.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.errc:
+		return c.err
+	case err := <-cl.err:
+		return err
+	}
+}
+
+// Err blocks until the client has shutdown and returns the final error.
+func (c *Client) Err() error {
+	<-c.errc
+	return c.err
+}
+
+// Wait is an alias for Err, provided for readability at call sites that are
+// waiting out the client's lifetime rather than inspecting its final error.
+func (c *Client) Wait() error {
+	return c.Err()
+}
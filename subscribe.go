@@ -0,0 +1,126 @@
+package wsrpc
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// subscriptionBuffer bounds the number of unread notifications buffered per
+// Subscription before the oldest is dropped to keep the client's read loop
+// from blocking on a slow consumer.
+const subscriptionBuffer = 64
+
+// Subscription is returned by Client.Subscribe and delivers notifications
+// matching the subscribed method as they arrive.
+//
+// Delivery never blocks the client's read loop: C is a bounded, buffered
+// channel, and once full, the oldest unread notification is dropped to make
+// room for the newest one.  Callers that cannot tolerate dropped
+// notifications should drain C promptly.
+type Subscription struct {
+	// Method is the notification method this subscription was registered
+	// for.
+	Method string
+	// C receives the Params of each matching notification.  C is closed
+	// by Close, or once the client has shut down (see Client.Err) and no
+	// further notifications will be delivered.
+	C <-chan json.RawMessage
+
+	client *Client
+	c      chan json.RawMessage
+	args   []interface{}
+	closed bool
+}
+
+// Subscribe performs the JSON-RPC call described by method with positional
+// parameters passed through args, which is expected to register interest in
+// a stream of server-pushed notifications, and returns a Subscription that
+// receives each notification whose method name matches method.
+//
+// Close the returned Subscription when no longer interested in its
+// notifications.
+//
+// If the client was dialed with WithAutoReconnect, the registration call is
+// replayed automatically after each reconnect, so the subscription survives
+// transient disconnects without the caller resubscribing.
+func (c *Client) Subscribe(ctx context.Context, method string, args ...interface{}) (*Subscription, error) {
+	if err := c.Call(ctx, method, nil, args...); err != nil {
+		return nil, err
+	}
+	ch := make(chan json.RawMessage, subscriptionBuffer)
+	sub := &Subscription{
+		Method: method,
+		C:      ch,
+		client: c,
+		c:      ch,
+		args:   args,
+	}
+	c.subMu.Lock()
+	c.subs[method] = append(c.subs[method], sub)
+	c.subMu.Unlock()
+	return sub, nil
+}
+
+// Close unregisters the subscription and closes its channel.  It does not
+// notify the server; if the server requires explicit unsubscription, the
+// caller must perform that Call separately.
+func (s *Subscription) Close() {
+	s.client.subMu.Lock()
+	defer s.client.subMu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	subs := s.client.subs[s.Method]
+	for i, x := range subs {
+		if x == s {
+			s.client.subs[s.Method] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	close(s.c)
+}
+
+// dispatchNotification delivers params to every live subscription matching
+// method, dropping the oldest buffered notification on a full channel rather
+// than blocking.
+//
+// The send happens while holding subMu, the same mutex Close and
+// closeSubscriptions hold while closing s.c, so a notification can never
+// race a close of the channel it is being sent on.
+func (c *Client) dispatchNotification(method string, params json.RawMessage) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	for _, s := range c.subs[method] {
+		if s.closed {
+			continue
+		}
+		select {
+		case s.c <- params:
+		default:
+			select {
+			case <-s.c:
+			default:
+			}
+			select {
+			case s.c <- params:
+			default:
+			}
+		}
+	}
+}
+
+// closeSubscriptions closes every live subscription's channel after the
+// client's connection is broken, so that range loops over Subscription.C
+// terminate.
+func (c *Client) closeSubscriptions() {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	for _, subs := range c.subs {
+		for _, s := range subs {
+			s.closed = true
+			close(s.c)
+		}
+	}
+	c.subs = nil
+}
@@ -0,0 +1,80 @@
+package wsrpc
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// TestDispatchNotificationRoutesByMethod checks that a notification is only
+// delivered to subscriptions registered for its method.
+func TestDispatchNotificationRoutesByMethod(t *testing.T) {
+	c := &Client{subs: make(map[string][]*Subscription)}
+
+	a := &Subscription{Method: "a", client: c, c: make(chan json.RawMessage, 1)}
+	b := &Subscription{Method: "b", client: c, c: make(chan json.RawMessage, 1)}
+	a.C, b.C = a.c, b.c
+	c.subs["a"] = []*Subscription{a}
+	c.subs["b"] = []*Subscription{b}
+
+	c.dispatchNotification("a", json.RawMessage(`1`))
+
+	select {
+	case got := <-a.c:
+		if string(got) != "1" {
+			t.Errorf("a.C = %s, want 1", got)
+		}
+	default:
+		t.Error("subscription a did not receive its notification")
+	}
+	select {
+	case got := <-b.c:
+		t.Errorf("subscription b received %s, want nothing", got)
+	default:
+	}
+}
+
+// TestDispatchNotificationDropsOldest checks that a full subscription
+// channel drops its oldest buffered notification rather than blocking the
+// dispatch.
+func TestDispatchNotificationDropsOldest(t *testing.T) {
+	c := &Client{subs: make(map[string][]*Subscription)}
+	s := &Subscription{Method: "m", client: c, c: make(chan json.RawMessage, 2)}
+	s.C = s.c
+	c.subs["m"] = []*Subscription{s}
+
+	c.dispatchNotification("m", json.RawMessage(`1`))
+	c.dispatchNotification("m", json.RawMessage(`2`))
+	c.dispatchNotification("m", json.RawMessage(`3`))
+
+	if got := string(<-s.c); got != "2" {
+		t.Errorf("first buffered value = %s, want 2 (1 should have been dropped)", got)
+	}
+	if got := string(<-s.c); got != "3" {
+		t.Errorf("second buffered value = %s, want 3", got)
+	}
+}
+
+// TestSubscriptionCloseRaceWithDispatch exercises Close racing concurrent
+// notification dispatch under the race detector: dispatchNotification must
+// never send on a channel that Close has already closed.
+func TestSubscriptionCloseRaceWithDispatch(t *testing.T) {
+	c := &Client{subs: make(map[string][]*Subscription)}
+	s := &Subscription{Method: "m", client: c, c: make(chan json.RawMessage, 1)}
+	s.C = s.c
+	c.subs["m"] = []*Subscription{s}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			c.dispatchNotification("m", json.RawMessage(`1`))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		s.Close()
+	}()
+	wg.Wait()
+}